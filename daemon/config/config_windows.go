@@ -1,13 +1,13 @@
 package config
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 
-	"github.com/containerd/log"
+	"github.com/Microsoft/hcsshim/osversion"
 )
 
 const (
@@ -18,6 +18,20 @@ const (
 
 	WindowsV1RuntimeName = "com.docker.hcsshim.v1"
 	WindowsV2RuntimeName = "io.containerd.runhcs.v1"
+
+	// minMTU and maxMTU bound the MTU accepted for the default "nat"
+	// network; values outside this range are rejected by HNS.
+	minMTU = 576
+	maxMTU = 9000
+
+	// FirewallBackendHNS is the default firewall backend, relying on HNS's
+	// own ACL policies for per-container ingress/egress rules.
+	FirewallBackendHNS = "hns"
+
+	// FirewallBackendWFP selects the Windows Filtering Platform backend. That
+	// backend programs no WFP filters: it returns an error for every
+	// published port and --firewall-rule entry it is given.
+	FirewallBackendWFP = "wfp"
 )
 
 var builtinRuntimes = map[string]bool{
@@ -25,6 +39,41 @@ var builtinRuntimes = map[string]bool{
 	WindowsV2RuntimeName: true,
 }
 
+// Runtime describes a user-defined OCI runtime, as registered through
+// daemon.json's "runtimes" field or the --add-runtime flag.
+type Runtime struct {
+	// Path is either the path to the runtime binary, or, for a containerd
+	// shim, the shim's runtime identifier (for example
+	// "io.containerd.runsc-wcow-hypervisor.v1").
+	Path string `json:"path"`
+
+	// Args is an optional list of arguments passed to the runtime binary.
+	Args []string `json:"runtimeArgs,omitempty"`
+}
+
+// LCOWConfig holds the defaults used to run Linux containers on Windows
+// (LCOW): the OCI runtime to use, and the utility-VM kernel, initrd, and
+// boot parameters to boot it with.
+//
+// There's intentionally no per-LCOW isolation setting here: LCOW containers
+// always run under Hyper-V isolation, which validatePlatformConfig requires
+// the host to support whenever lcow-runtime or lcow-bootparameters is set.
+// A DefaultPlatform of "linux" alone does not trigger that check.
+type LCOWConfig struct {
+	// Runtime is the OCI runtime used for LCOW containers.
+	Runtime string `json:"lcow-runtime,omitempty"`
+
+	// Kernel is the path to the Linux kernel used to boot the utility VM.
+	Kernel string `json:"lcow-kernel,omitempty"`
+
+	// KirdPath is the path to the initrd used to boot the utility VM.
+	KirdPath string `json:"lcow-kirdpath,omitempty"`
+
+	// BootParameters are additional kernel command-line parameters passed
+	// to the utility VM.
+	BootParameters string `json:"lcow-bootparameters,omitempty"`
+}
+
 // BridgeConfig is meant to store all the parameters for both the bridge driver and the default bridge network. On
 // Windows: 1. "bridge" in this context reference the nat driver and the default nat network; 2. the nat driver has no
 // specific parameters, so this struct effectively just stores parameters for the default nat network.
@@ -35,9 +84,14 @@ type BridgeConfig struct {
 type DefaultBridgeConfig struct {
 	commonBridgeConfig
 
-	// MTU is not actually used on Windows, but the --mtu option has always
-	// been there on Windows (but ignored).
+	// MTU is applied to the default "nat" network's HNS network as
+	// MaxMTU when the network is (re)created at daemon startup.
 	MTU int `json:"mtu,omitempty"`
+
+	// FixedCIDRGateway is the gateway address to use for the default
+	// "nat" network's subnet, set through the HNSNetwork's Subnets. It
+	// must be contained within FixedCIDR.
+	FixedCIDRGateway string `json:"fixed-cidr-gateway,omitempty"`
 }
 
 // Config defines the configuration of a docker daemon.
@@ -46,8 +100,26 @@ type DefaultBridgeConfig struct {
 type Config struct {
 	CommonConfig
 
-	// Fields below here are platform specific. (There are none presently
-	// for the Windows daemon.)
+	// Fields below here are platform specific.
+
+	// Runtimes is the list of OCI runtimes that can be configured through
+	// daemon.json or the --add-runtime flag, in addition to the built-in
+	// runtimes named in builtinRuntimes.
+	Runtimes map[string]Runtime `json:"runtimes,omitempty"`
+
+	// DefaultRuntime is the name of the OCI runtime to use by default. It
+	// must name either a built-in runtime or an entry in Runtimes.
+	DefaultRuntime string `json:"default-runtime,omitempty"`
+
+	// DefaultPlatform is the default container platform ("windows" or
+	// "linux") used when a container doesn't explicitly request one. It
+	// determines whether containers run natively or through LCOW.
+	DefaultPlatform string `json:"default-platform,omitempty"`
+
+	// LCOWConfig holds the defaults used to run Linux containers on
+	// Windows. Its fields are flattened into daemon.json, matching the
+	// "lcow-*" flag names.
+	LCOWConfig
 }
 
 // GetExecRoot returns the user configured Exec-root
@@ -77,24 +149,161 @@ func (conf *Config) IsRootless() bool {
 	return false
 }
 
+// resolveRuntimeName applies name against defaultName (used when name is
+// empty) and falls back to the built-in Windows v2 runtime when both are
+// empty or StockRuntimeName.
+func resolveRuntimeName(defaultName, name string) string {
+	if name == "" {
+		name = defaultName
+	}
+	if name == "" || name == StockRuntimeName {
+		name = WindowsV2RuntimeName
+	}
+	return name
+}
+
+// GetRuntime returns the runtime to use for name, which may be a built-in
+// runtime, a user-defined entry in Runtimes, or empty (in which case the
+// configured default, or the built-in default, is returned). It returns
+// false if name does not match a known runtime.
+func (conf *Config) GetRuntime(name string) (Runtime, bool) {
+	name = resolveRuntimeName(conf.DefaultRuntime, name)
+	if builtinRuntimes[name] {
+		return Runtime{Path: name}, true
+	}
+	rt, ok := conf.Runtimes[name]
+	return rt, ok
+}
+
+// RuntimeForPlatform resolves the OCI runtime to use for a container whose
+// target platform is platform ("windows" or "linux"). If platform is empty,
+// conf.DefaultPlatform is used.
+//
+// A container whose target platform matches the host (the native-Windows
+// case) resolves exactly like GetRuntime(""), so a configured
+// --default-runtime is honored. Only LCOW (platform "linux" on a Windows
+// host) forces the Windows v2 runtime by default, resolving through
+// LCOWConfig.Runtime when set.
+func (conf *Config) RuntimeForPlatform(platform string) (string, Runtime, bool) {
+	if platform == "" {
+		platform = conf.DefaultPlatform
+	}
+	defaultName, requested := conf.DefaultRuntime, ""
+	if platform == "linux" {
+		defaultName, requested = WindowsV2RuntimeName, conf.LCOWConfig.Runtime
+	}
+	name := resolveRuntimeName(defaultName, requested)
+	rt, ok := conf.GetRuntime(name)
+	return name, rt, ok
+}
+
+// requestsLCOW reports whether LCOWConfig.Runtime or BootParameters is set,
+// as opposed to LCOWConfig merely holding the paths setPlatformDefaults always
+// fills in. Runtime and BootParameters are never touched by
+// setPlatformDefaults, so either being set is a reliable signal of intent.
+// A Kernel or KirdPath set by the user is not told apart from those
+// defaults and does not count.
+func (conf *Config) requestsLCOW() bool {
+	return conf.LCOWConfig.Runtime != "" || conf.LCOWConfig.BootParameters != ""
+}
+
 func setPlatformDefaults(cfg *Config) error {
 	cfg.Root = filepath.Join(os.Getenv("programdata"), "docker")
 	cfg.ExecRoot = filepath.Join(os.Getenv("programdata"), "docker", "exec-root")
 	cfg.Pidfile = filepath.Join(cfg.Root, "docker.pid")
+
+	lcowRoot := filepath.Join(os.Getenv("programdata"), "docker", "lcow")
+	cfg.Kernel = filepath.Join(lcowRoot, "kernel")
+	cfg.KirdPath = filepath.Join(lcowRoot, "initrd.img")
 	return nil
 }
 
 // validatePlatformConfig checks if any platform-specific configuration settings are invalid.
 func validatePlatformConfig(conf *Config) error {
-	if conf.MTU != 0 && conf.MTU != DefaultNetworkMtu {
-		log.G(context.TODO()).Warn(`WARNING: MTU for the default network is not configurable on Windows, and this option will be ignored.`)
+	if conf.MTU != 0 && (conf.MTU < minMTU || conf.MTU > maxMTU) {
+		return fmt.Errorf("invalid default MTU %d: must be between %d and %d", conf.MTU, minMTU, maxMTU)
+	}
+	if conf.FixedCIDRGateway != "" {
+		gateway := net.ParseIP(conf.FixedCIDRGateway)
+		if gateway == nil {
+			return fmt.Errorf("invalid fixed-cidr-gateway: %q is not an IP address", conf.FixedCIDRGateway)
+		}
+		if conf.FixedCIDR == "" {
+			return errors.New("fixed-cidr-gateway requires fixed-cidr to be set")
+		}
+		_, subnet, err := net.ParseCIDR(conf.FixedCIDR)
+		if err != nil {
+			return fmt.Errorf("invalid fixed-cidr: %w", err)
+		}
+		if !subnet.Contains(gateway) {
+			return fmt.Errorf("fixed-cidr-gateway %s is not contained within fixed-cidr %s", gateway, subnet)
+		}
+	}
+	switch conf.DefaultPlatform {
+	case "", "windows", "linux":
+	default:
+		return fmt.Errorf("invalid default-platform %q: must be %q or %q", conf.DefaultPlatform, "windows", "linux")
+	}
+	switch conf.FirewallBackend {
+	case "", FirewallBackendHNS, FirewallBackendWFP:
+	default:
+		return fmt.Errorf("invalid firewall-backend %q: must be %q or %q", conf.FirewallBackend, FirewallBackendHNS, FirewallBackendWFP)
+	}
+	for name := range conf.Runtimes {
+		if builtinRuntimes[name] {
+			return fmt.Errorf("runtime name '%s' is reserved", name)
+		}
 	}
-	if conf.FirewallBackend != "" {
-		return errors.New("firewall-backend can only be configured on Linux")
+	if conf.DefaultRuntime != "" {
+		if _, ok := conf.GetRuntime(conf.DefaultRuntime); !ok {
+			return fmt.Errorf("specified default runtime '%s' does not exist", conf.DefaultRuntime)
+		}
+	}
+	if conf.requestsLCOW() && !hyperVIsolationSupported() {
+		return errors.New("LCOW requires a host that supports Hyper-V isolation")
 	}
 	return nil
 }
 
+// HNSSubnet holds the AddressPrefix/GatewayAddress pair used to populate an
+// HNSNetwork request's Subnets field.
+type HNSSubnet struct {
+	AddressPrefix  string
+	GatewayAddress string
+}
+
+// HNSNetworkSettings holds the Subnets/MaxMTU values daemon startup passes
+// to HNS when (re)creating the default "nat" network, and that are
+// surfaced back through `docker info`.
+type HNSNetworkSettings struct {
+	Subnets []HNSSubnet
+	MaxMTU  uint32
+}
+
+// NATNetworkSettings translates the configured MTU, FixedCIDR, and
+// FixedCIDRGateway into the Subnets/MaxMTU fields of the HNSNetwork request
+// used to (re)create the default "nat" network at daemon startup.
+func (conf *Config) NATNetworkSettings() HNSNetworkSettings {
+	var settings HNSNetworkSettings
+	if conf.MTU != 0 {
+		settings.MaxMTU = uint32(conf.MTU)
+	}
+	if conf.FixedCIDR != "" {
+		settings.Subnets = append(settings.Subnets, HNSSubnet{
+			AddressPrefix:  conf.FixedCIDR,
+			GatewayAddress: conf.FixedCIDRGateway,
+		})
+	}
+	return settings
+}
+
+// hyperVIsolationSupported reports whether the host Windows build supports
+// Hyper-V isolation, which LCOW containers always run under. It's a
+// variable so it can be stubbed out in tests.
+var hyperVIsolationSupported = func() bool {
+	return osversion.Build() >= osversion.RS5
+}
+
 // validatePlatformExecOpt validates if the given exec-opt and value are valid
 // for the current platform.
 func validatePlatformExecOpt(opt, value string) error {
@@ -102,6 +311,8 @@ func validatePlatformExecOpt(opt, value string) error {
 	case "isolation":
 		// TODO(thaJeztah): add validation that's currently in Daemon.setDefaultIsolation()
 		return nil
+	case "lcow.kernel", "lcow.kirdpath", "lcow.bootparameters":
+		return nil
 	case "native.cgroupdriver":
 		return fmt.Errorf("option '%s' is only supported on linux", opt)
 	default:
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestGetRuntime(t *testing.T) {
+	conf := &Config{
+		Runtimes: map[string]Runtime{
+			"my-shim": {Path: "my-shim.exe"},
+		},
+	}
+
+	rt, ok := conf.GetRuntime("my-shim")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(rt.Path, "my-shim.exe"))
+
+	rt, ok = conf.GetRuntime("")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(rt.Path, WindowsV2RuntimeName))
+
+	_, ok = conf.GetRuntime("does-not-exist")
+	assert.Check(t, !ok)
+}
+
+func TestGetRuntimeDefaultRuntime(t *testing.T) {
+	conf := &Config{
+		Runtimes:       map[string]Runtime{"my-shim": {Path: "my-shim.exe"}},
+		DefaultRuntime: "my-shim",
+	}
+
+	rt, ok := conf.GetRuntime("")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(rt.Path, "my-shim.exe"))
+}
+
+func TestRuntimeForPlatformNativeHonorsDefaultRuntime(t *testing.T) {
+	conf := &Config{
+		Runtimes:       map[string]Runtime{"my-shim": {Path: "my-shim.exe"}},
+		DefaultRuntime: "my-shim",
+	}
+
+	// The common native-Windows case: platform matches the host. This must
+	// not bypass DefaultRuntime in favor of hard-coding the built-in v2
+	// runtime.
+	name, rt, ok := conf.RuntimeForPlatform("windows")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(name, "my-shim"))
+	assert.Check(t, is.Equal(rt.Path, "my-shim.exe"))
+}
+
+func TestRuntimeForPlatformNativeFallsBackToWindowsV2(t *testing.T) {
+	conf := &Config{}
+
+	name, rt, ok := conf.RuntimeForPlatform("windows")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(name, WindowsV2RuntimeName))
+	assert.Check(t, is.Equal(rt.Path, WindowsV2RuntimeName))
+}
+
+func TestRuntimeForPlatformLCOW(t *testing.T) {
+	conf := &Config{
+		Runtimes:       map[string]Runtime{"my-shim": {Path: "my-shim.exe"}},
+		DefaultRuntime: "my-shim",
+		LCOWConfig:     LCOWConfig{Runtime: WindowsV2RuntimeName},
+	}
+
+	// LCOW must resolve through LCOWConfig.Runtime, not the native
+	// DefaultRuntime.
+	name, rt, ok := conf.RuntimeForPlatform("linux")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(name, WindowsV2RuntimeName))
+	assert.Check(t, is.Equal(rt.Path, WindowsV2RuntimeName))
+}
+
+func TestRuntimeForPlatformLCOWDefaultsToWindowsV2(t *testing.T) {
+	conf := &Config{}
+
+	name, _, ok := conf.RuntimeForPlatform("linux")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(name, WindowsV2RuntimeName))
+}
+
+func TestValidatePlatformConfigReservedRuntimeName(t *testing.T) {
+	conf := &Config{
+		Runtimes: map[string]Runtime{WindowsV2RuntimeName: {Path: "evil.exe"}},
+	}
+
+	err := validatePlatformConfig(conf)
+	assert.Check(t, is.ErrorContains(err, "is reserved"))
+}
+
+func TestValidatePlatformConfigDefaultRuntimeNotFound(t *testing.T) {
+	conf := &Config{DefaultRuntime: "does-not-exist"}
+
+	err := validatePlatformConfig(conf)
+	assert.Check(t, is.ErrorContains(err, "does not exist"))
+}
+
+func TestValidatePlatformConfigDefaultRuntimeUserDefined(t *testing.T) {
+	conf := &Config{
+		Runtimes:       map[string]Runtime{"my-shim": {Path: "my-shim.exe"}},
+		DefaultRuntime: "my-shim",
+	}
+
+	assert.NilError(t, validatePlatformConfig(conf))
+}
+
+func bridgeConfig(mtu int, fixedCIDR, fixedCIDRGateway string) CommonConfig {
+	return CommonConfig{
+		BridgeConfig: BridgeConfig{
+			DefaultBridgeConfig: DefaultBridgeConfig{
+				commonBridgeConfig: commonBridgeConfig{FixedCIDR: fixedCIDR},
+				MTU:                mtu,
+				FixedCIDRGateway:   fixedCIDRGateway,
+			},
+		},
+	}
+}
+
+func TestValidatePlatformConfigMTU(t *testing.T) {
+	for _, mtu := range []int{0, minMTU, maxMTU, 1500} {
+		conf := &Config{CommonConfig: bridgeConfig(mtu, "", "")}
+		assert.Check(t, validatePlatformConfig(conf), "mtu %d should be valid", mtu)
+	}
+
+	for _, mtu := range []int{minMTU - 1, maxMTU + 1} {
+		conf := &Config{CommonConfig: bridgeConfig(mtu, "", "")}
+		err := validatePlatformConfig(conf)
+		assert.Check(t, is.ErrorContains(err, "invalid default MTU"), "mtu %d should be rejected", mtu)
+	}
+}
+
+func TestValidatePlatformConfigFixedCIDRGateway(t *testing.T) {
+	conf := &Config{CommonConfig: bridgeConfig(0, "172.20.0.0/16", "172.20.0.1")}
+	assert.NilError(t, validatePlatformConfig(conf))
+
+	conf = &Config{CommonConfig: bridgeConfig(0, "172.20.0.0/16", "10.0.0.1")}
+	err := validatePlatformConfig(conf)
+	assert.Check(t, is.ErrorContains(err, "is not contained within"))
+
+	conf = &Config{CommonConfig: bridgeConfig(0, "", "172.20.0.1")}
+	err = validatePlatformConfig(conf)
+	assert.Check(t, is.ErrorContains(err, "requires fixed-cidr"))
+}
+
+func TestNATNetworkSettings(t *testing.T) {
+	conf := &Config{CommonConfig: bridgeConfig(1400, "172.20.0.0/16", "172.20.0.1")}
+
+	settings := conf.NATNetworkSettings()
+	assert.Check(t, is.Equal(settings.MaxMTU, uint32(1400)))
+	assert.Check(t, is.Len(settings.Subnets, 1))
+	assert.Check(t, is.Equal(settings.Subnets[0].AddressPrefix, "172.20.0.0/16"))
+	assert.Check(t, is.Equal(settings.Subnets[0].GatewayAddress, "172.20.0.1"))
+}
+
+func TestNATNetworkSettingsEmpty(t *testing.T) {
+	settings := (&Config{}).NATNetworkSettings()
+	assert.Check(t, is.Equal(settings.MaxMTU, uint32(0)))
+	assert.Check(t, is.Len(settings.Subnets, 0))
+}
+
+func withHyperVIsolationSupported(t *testing.T, supported bool) {
+	t.Helper()
+	orig := hyperVIsolationSupported
+	hyperVIsolationSupported = func() bool { return supported }
+	t.Cleanup(func() { hyperVIsolationSupported = orig })
+}
+
+func TestValidatePlatformConfigSetPlatformDefaultsDoesNotRequireHyperV(t *testing.T) {
+	withHyperVIsolationSupported(t, false)
+
+	// setPlatformDefaults always fills in Kernel/KirdPath, regardless of
+	// whether the user asked for LCOW. A daemon that never touched any
+	// lcow-* option must still start on a host without Hyper-V isolation
+	// support.
+	conf := &Config{}
+	assert.NilError(t, setPlatformDefaults(conf))
+	assert.Check(t, conf.Kernel != "")
+
+	assert.NilError(t, validatePlatformConfig(conf))
+}
+
+func TestValidatePlatformConfigLCOWRequestedRequiresHyperV(t *testing.T) {
+	withHyperVIsolationSupported(t, false)
+
+	conf := &Config{}
+	assert.NilError(t, setPlatformDefaults(conf))
+	conf.LCOWConfig.Runtime = WindowsV2RuntimeName
+
+	err := validatePlatformConfig(conf)
+	assert.Check(t, is.ErrorContains(err, "requires a host that supports Hyper-V isolation"))
+}
+
+func TestValidatePlatformConfigLCOWRequestedHyperVSupported(t *testing.T) {
+	withHyperVIsolationSupported(t, true)
+
+	conf := &Config{}
+	assert.NilError(t, setPlatformDefaults(conf))
+	conf.LCOWConfig.BootParameters = "debug"
+
+	assert.NilError(t, validatePlatformConfig(conf))
+}
+
+func TestValidatePlatformConfigDefaultPlatform(t *testing.T) {
+	for _, platform := range []string{"", "windows", "linux"} {
+		conf := &Config{DefaultPlatform: platform}
+		assert.Check(t, validatePlatformConfig(conf), "platform %q should be valid", platform)
+	}
+
+	conf := &Config{DefaultPlatform: "foo"}
+	err := validatePlatformConfig(conf)
+	assert.Check(t, is.ErrorContains(err, "invalid default-platform"))
+}
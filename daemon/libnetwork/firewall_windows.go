@@ -0,0 +1,54 @@
+package libnetwork
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/daemon/config"
+)
+
+// FirewallBackend programs per-container ingress/egress rules for published
+// ports and user-specified --firewall-rule entries. The implementation used
+// is selected through daemon.json's firewall-backend option.
+type FirewallBackend interface {
+	// AllowPort opens ingress for a published container port.
+	AllowPort(proto string, port uint16) error
+
+	// ApplyRule programs a user-specified --firewall-rule entry.
+	ApplyRule(rule string) error
+}
+
+// NewFirewallBackend returns the FirewallBackend for name, which must be
+// config.FirewallBackendHNS, config.FirewallBackendWFP, or empty (which
+// selects the default, HNS, backend).
+func NewFirewallBackend(name string) (FirewallBackend, error) {
+	switch name {
+	case "", config.FirewallBackendHNS:
+		return &hnsFirewallBackend{}, nil
+	case config.FirewallBackendWFP:
+		return &wfpFirewallBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown firewall backend: %q", name)
+	}
+}
+
+// hnsFirewallBackend is the default backend: ingress/egress for published
+// ports and --firewall-rule entries is already enforced by HNS's own ACL
+// policies on the endpoint, so these are no-ops.
+type hnsFirewallBackend struct{}
+
+func (*hnsFirewallBackend) AllowPort(proto string, port uint16) error { return nil }
+
+func (*hnsFirewallBackend) ApplyRule(rule string) error { return nil }
+
+// wfpFirewallBackend is the backend selected by config.FirewallBackendWFP.
+// It programs no Windows Filtering Platform filters: both methods return an
+// error rather than silently doing nothing.
+type wfpFirewallBackend struct{}
+
+func (*wfpFirewallBackend) AllowPort(proto string, port uint16) error {
+	return fmt.Errorf("firewall-backend %q is not implemented yet", config.FirewallBackendWFP)
+}
+
+func (*wfpFirewallBackend) ApplyRule(rule string) error {
+	return fmt.Errorf("firewall-backend %q is not implemented yet", config.FirewallBackendWFP)
+}
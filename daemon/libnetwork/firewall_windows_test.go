@@ -0,0 +1,32 @@
+package libnetwork
+
+import (
+	"testing"
+
+	"github.com/docker/docker/daemon/config"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestNewFirewallBackendHNS(t *testing.T) {
+	for _, name := range []string{"", config.FirewallBackendHNS} {
+		backend, err := NewFirewallBackend(name)
+		assert.NilError(t, err)
+		assert.Check(t, is.DeepEqual(backend, FirewallBackend(&hnsFirewallBackend{})))
+		assert.NilError(t, backend.AllowPort("tcp", 80))
+		assert.NilError(t, backend.ApplyRule("deny 8.8.8.8"))
+	}
+}
+
+func TestNewFirewallBackendWFPNotImplemented(t *testing.T) {
+	backend, err := NewFirewallBackend(config.FirewallBackendWFP)
+	assert.NilError(t, err)
+
+	assert.Check(t, is.ErrorContains(backend.AllowPort("tcp", 80), "not implemented yet"))
+	assert.Check(t, is.ErrorContains(backend.ApplyRule("deny 8.8.8.8"), "not implemented yet"))
+}
+
+func TestNewFirewallBackendUnknown(t *testing.T) {
+	_, err := NewFirewallBackend("iptables")
+	assert.Check(t, is.ErrorContains(err, "unknown firewall backend"))
+}